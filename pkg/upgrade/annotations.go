@@ -0,0 +1,61 @@
+package upgrade
+
+import (
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/jaegertracing/jaeger-operator/pkg/apis/jaegertracing/v1"
+)
+
+const (
+	// AnnotationPinVersion freezes a Jaeger instance at a specific version, preventing the
+	// upgrade loop from advancing it any further even when newer versions are known
+	AnnotationPinVersion = "jaegertracing.io/pin-version"
+
+	// AnnotationUnsafeSkipUpgrade opts a Jaeger instance out of the upgrade loop entirely.
+	// It is named "unsafe" to discourage casual use: an instance left here will not receive
+	// any of the schema/config migrations that later upgrade functions perform
+	AnnotationUnsafeSkipUpgrade = "jaegertracing.io/unsafe-skip-upgrade"
+)
+
+// recordSkipped marks the instance as intentionally left out of the upgrade loop because of
+// the unsafe-skip-upgrade annotation
+func recordSkipped(jaeger *v1.Jaeger) {
+	log.WithFields(log.Fields{
+		"instance":  jaeger.Name,
+		"namespace": jaeger.Namespace,
+	}).Info("skipping upgrade for instance: " + AnnotationUnsafeSkipUpgrade + " is set")
+
+	jaeger.Status.UpgradeState.Skipped = true
+	jaeger.Status.UpgradeState.LastAttempt = metav1.Now()
+	jaeger.Status.UpgradeState.LastError = ""
+
+	condition := v1.JaegerCondition{
+		Type:               v1.ConditionUpgradeable,
+		Status:             corev1.ConditionFalse,
+		Reason:             "UpgradeSkipped",
+		Message:            "upgrade skipped: " + AnnotationUnsafeSkipUpgrade + " annotation is set",
+		LastTransitionTime: metav1.Now(),
+	}
+	for i, c := range jaeger.Status.Conditions {
+		if c.Type == v1.ConditionUpgradeable {
+			jaeger.Status.Conditions[i] = condition
+			return
+		}
+	}
+	jaeger.Status.Conditions = append(jaeger.Status.Conditions, condition)
+}
+
+// pinnedVersion returns the version this instance is pinned to via AnnotationPinVersion, and
+// whether the annotation was present at all
+func pinnedVersion(jaeger v1.Jaeger) (string, bool) {
+	v, ok := jaeger.Annotations[AnnotationPinVersion]
+	return v, ok
+}
+
+// skipUpgrade reports whether this instance opted out of the upgrade loop via
+// AnnotationUnsafeSkipUpgrade
+func skipUpgrade(jaeger v1.Jaeger) bool {
+	return jaeger.Annotations[AnnotationUnsafeSkipUpgrade] == "true"
+}
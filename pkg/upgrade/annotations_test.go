@@ -0,0 +1,71 @@
+package upgrade
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v1 "github.com/jaegertracing/jaeger-operator/pkg/apis/jaegertracing/v1"
+)
+
+func TestPinnedVersionReturnsAnnotationValue(t *testing.T) {
+	jaeger := v1.Jaeger{}
+	jaeger.Annotations = map[string]string{AnnotationPinVersion: "1.21.0"}
+
+	v, ok := pinnedVersion(jaeger)
+
+	assert.True(t, ok)
+	assert.Equal(t, "1.21.0", v)
+}
+
+func TestPinnedVersionAbsentWhenAnnotationNotSet(t *testing.T) {
+	_, ok := pinnedVersion(v1.Jaeger{})
+
+	assert.False(t, ok)
+}
+
+func TestSkipUpgrade(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{name: "unset", want: false},
+		{name: "false", annotations: map[string]string{AnnotationUnsafeSkipUpgrade: "false"}, want: false},
+		{name: "true", annotations: map[string]string{AnnotationUnsafeSkipUpgrade: "true"}, want: true},
+		{name: "garbage value", annotations: map[string]string{AnnotationUnsafeSkipUpgrade: "yes"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jaeger := v1.Jaeger{}
+			jaeger.Annotations = tt.annotations
+
+			assert.Equal(t, tt.want, skipUpgrade(jaeger))
+		})
+	}
+}
+
+func TestRecordSkippedSetsUpgradeStateAndCondition(t *testing.T) {
+	jaeger := v1.Jaeger{}
+
+	recordSkipped(&jaeger)
+
+	assert.True(t, jaeger.Status.UpgradeState.Skipped)
+	assert.Empty(t, jaeger.Status.UpgradeState.LastError)
+
+	assert.Len(t, jaeger.Status.Conditions, 1)
+	assert.Equal(t, v1.ConditionUpgradeable, jaeger.Status.Conditions[0].Type)
+}
+
+func TestRecordSkippedReplacesExistingUpgradeableCondition(t *testing.T) {
+	jaeger := v1.Jaeger{}
+	jaeger.Status.Conditions = []v1.JaegerCondition{
+		{Type: v1.ConditionUpgradeable, Reason: "PreconditionFailed"},
+	}
+
+	recordSkipped(&jaeger)
+
+	assert.Len(t, jaeger.Status.Conditions, 1)
+	assert.Equal(t, "UpgradeSkipped", jaeger.Status.Conditions[0].Reason)
+}
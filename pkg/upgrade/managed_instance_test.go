@@ -0,0 +1,48 @@
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1 "github.com/jaegertracing/jaeger-operator/pkg/apis/jaegertracing/v1"
+)
+
+func noopMigration(ctx context.Context, c client.Client, jaeger v1.Jaeger) (v1.Jaeger, error) {
+	return jaeger, nil
+}
+
+func failingMigration(ctx context.Context, c client.Client, jaeger v1.Jaeger) (v1.Jaeger, error) {
+	return jaeger, errors.New("migration blew up")
+}
+
+func TestManagedInstanceAbortsMidPathOnHopFailure(t *testing.T) {
+	defer func(old map[string]version) { versions = old }(versions)
+
+	v100 := version{v: "1.0.0"}
+	v110 := version{v: "1.1.0", upgrade: noopMigration}
+	v120 := version{v: "1.2.0", upgrade: failingMigration}
+	link(&v100, &v110)
+	link(&v110, &v120)
+
+	versions = map[string]version{
+		v100.v: v100,
+		v110.v: v110,
+		v120.v: v120,
+	}
+
+	jaeger := v1.Jaeger{}
+	jaeger.Status.Version = "1.0.0"
+	jaeger.Spec.Version = "1.2.0"
+
+	result, err := ManagedInstance(context.Background(), fake.NewFakeClient(), jaeger)
+
+	assert.Error(t, err)
+	// the first hop (1.0.0 -> 1.1.0) succeeded, the second (1.1.0 -> 1.2.0) failed: the CR
+	// should be left at the last successfully-completed hop, not the original or the target
+	assert.Equal(t, "1.1.0", result.Status.Version)
+}
@@ -0,0 +1,98 @@
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	v1 "github.com/jaegertracing/jaeger-operator/pkg/apis/jaegertracing/v1"
+)
+
+// conflictingClient fails the first N calls to Patch with a genuine IsConflict error before
+// delegating to the wrapped client, so tests can exercise retry.RetryOnConflict for real
+type conflictingClient struct {
+	client.Client
+	conflictsLeft int
+	patchCalls    int
+}
+
+func (c *conflictingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.patchCalls++
+	if c.conflictsLeft > 0 {
+		c.conflictsLeft--
+		return apierrors.NewConflict(schema.GroupResource{Group: "jaegertracing.io", Resource: "jaegers"}, obj.GetName(), fmt.Errorf("stale resourceVersion"))
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestPatchInstanceRetainsConcurrentChanges(t *testing.T) {
+	original := &v1.Jaeger{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-instance", Namespace: "observability"},
+	}
+	original.Status.Version = "1.20.0"
+
+	c := fake.NewFakeClient(original.DeepCopy())
+
+	// simulate a concurrent reconcile updating the object between our read and our patch
+	stale := &v1.Jaeger{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: original.Name, Namespace: original.Namespace}, stale))
+	stale.Labels = map[string]string{"touched-by": "main-controller"}
+	assert.NoError(t, c.Update(context.Background(), stale))
+
+	updated := original.DeepCopy()
+	updated.Status.Version = "1.21.0"
+
+	err := patchInstance(context.Background(), c, original, updated)
+
+	assert.NoError(t, err)
+
+	persisted := &v1.Jaeger{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: original.Name, Namespace: original.Namespace}, persisted))
+	assert.Equal(t, "1.21.0", persisted.Status.Version)
+	assert.Equal(t, "main-controller", persisted.Labels["touched-by"])
+}
+
+func TestPatchInstanceRetriesOnConflict(t *testing.T) {
+	original := &v1.Jaeger{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-instance", Namespace: "observability"},
+	}
+	original.Status.Version = "1.20.0"
+
+	c := &conflictingClient{
+		Client:        fake.NewFakeClient(original.DeepCopy()),
+		conflictsLeft: 2,
+	}
+
+	updated := original.DeepCopy()
+	updated.Status.Version = "1.21.0"
+
+	err := patchInstance(context.Background(), c, original, updated)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, c.patchCalls, "expected two failed attempts before the third succeeds")
+
+	persisted := &v1.Jaeger{}
+	assert.NoError(t, c.Get(context.Background(), client.ObjectKey{Name: original.Name, Namespace: original.Namespace}, persisted))
+	assert.Equal(t, "1.21.0", persisted.Status.Version)
+}
+
+func TestPatchInstanceGivesUpOnNonConflictError(t *testing.T) {
+	original := &v1.Jaeger{
+		ObjectMeta: metav1.ObjectMeta{Name: "does-not-exist", Namespace: "observability"},
+	}
+	updated := original.DeepCopy()
+
+	c := fake.NewFakeClient()
+
+	err := patchInstance(context.Background(), c, original, updated)
+
+	assert.Error(t, err)
+	assert.True(t, apierrors.IsNotFound(err))
+}
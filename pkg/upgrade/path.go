@@ -0,0 +1,42 @@
+package upgrade
+
+import "fmt"
+
+// hop is one step of a path through the upgrade graph: move to 'to', either by calling
+// its upgrade function (forward) or the downgrade function of the version being left,
+// 'to.next' (backward)
+type hop struct {
+	to      *version
+	forward bool
+}
+
+// pathTo walks the upgrade graph from 'from' to 'to' and returns the ordered sequence of
+// hops needed to get there. Since the graph is currently a simple doubly linked list, the
+// shortest (and only) path is found by walking in one direction until 'to' is reached; an
+// error is returned if the two versions aren't on the same line
+func pathTo(from, to *version) ([]hop, error) {
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("cannot compute an upgrade path without both a source and a target version")
+	}
+	if from.v == to.v {
+		return nil, nil
+	}
+
+	var path []hop
+	for n := from.next; n != nil; n = n.next {
+		path = append(path, hop{to: n, forward: true})
+		if n.v == to.v {
+			return path, nil
+		}
+	}
+
+	path = nil
+	for n := from.prev; n != nil; n = n.prev {
+		path = append(path, hop{to: n, forward: false})
+		if n.v == to.v {
+			return path, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no upgrade path from version %s to %s", from.v, to.v)
+}
@@ -0,0 +1,72 @@
+package upgrade
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func chain(vs ...string) map[string]version {
+	nodes := make([]*version, len(vs))
+	for i, v := range vs {
+		nodes[i] = &version{v: v}
+	}
+	for i := 1; i < len(nodes); i++ {
+		link(nodes[i-1], nodes[i])
+	}
+	m := make(map[string]version, len(nodes))
+	for _, n := range nodes {
+		m[n.v] = *n
+	}
+	return m
+}
+
+func TestPathToSkipsForwardSeveralVersions(t *testing.T) {
+	vs := chain("1.20.0", "1.21.0", "1.22.0", "1.23.0")
+	from := vs["1.20.0"]
+	to := vs["1.23.0"]
+
+	path, err := pathTo(&from, &to)
+
+	assert.NoError(t, err)
+	assert.Len(t, path, 3)
+	for _, h := range path {
+		assert.True(t, h.forward)
+	}
+	assert.Equal(t, "1.23.0", path[len(path)-1].to.v)
+}
+
+func TestPathToRollsBackOneVersion(t *testing.T) {
+	vs := chain("1.20.0", "1.21.0", "1.22.0")
+	from := vs["1.22.0"]
+	to := vs["1.21.0"]
+
+	path, err := pathTo(&from, &to)
+
+	assert.NoError(t, err)
+	assert.Len(t, path, 1)
+	assert.False(t, path[0].forward)
+	assert.Equal(t, "1.21.0", path[0].to.v)
+}
+
+func TestPathToNoPathBetweenUnrelatedVersions(t *testing.T) {
+	vs := chain("1.20.0", "1.21.0")
+	other := version{v: "9.9.9"}
+	from := vs["1.20.0"]
+
+	path, err := pathTo(&from, &other)
+
+	assert.Error(t, err)
+	assert.Nil(t, path)
+}
+
+func TestPathToSameVersionIsNoOp(t *testing.T) {
+	vs := chain("1.20.0")
+	from := vs["1.20.0"]
+	to := vs["1.20.0"]
+
+	path, err := pathTo(&from, &to)
+
+	assert.NoError(t, err)
+	assert.Nil(t, path)
+}
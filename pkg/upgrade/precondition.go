@@ -0,0 +1,189 @@
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/jaegertracing/jaeger-operator/pkg/apis/jaegertracing/v1"
+)
+
+// overrideAnnotation lets a cluster admin force an upgrade through even when a blocking
+// precondition would otherwise hold it back, mirroring how cluster-scoped overrides are
+// expressed in other operators
+const overrideAnnotation = "jaegertracing.io/disable-upgrade-preconditions"
+
+// ReleaseContext carries everything a Precondition needs to decide whether it's safe to
+// move a given Jaeger instance from its current version to the next one
+type ReleaseContext struct {
+	Jaeger v1.Jaeger
+	From   string
+	To     string
+}
+
+// Precondition is checked before ManagedInstance upgrades an instance to a new version.
+// A failing Precondition either blocks the upgrade outright or is merely reported,
+// depending on its Blocking value
+type Precondition interface {
+	// Name identifies the precondition, used in status conditions and logs
+	Name() string
+
+	// Blocking reports whether a failure from Run should prevent the upgrade from proceeding
+	Blocking() bool
+
+	// Run evaluates the precondition for the given release context. A non-nil error means
+	// the precondition was not satisfied
+	Run(ctx context.Context, c client.Client, rc ReleaseContext) error
+}
+
+// List is an ordered collection of preconditions to run together
+type List []Precondition
+
+// defaultPreconditions are run for every upgrade; RegisterPrecondition appends further ones to them
+var defaultPreconditions = List{
+	storageReachablePrecondition{},
+	rolloutCompletePrecondition{},
+	noOverridePrecondition{},
+}
+
+// RegisterPrecondition adds a precondition to the set that RunAll executes for every
+// instance upgrade. It is meant to be called from an init function by code that extends
+// the operator with additional checks
+func RegisterPrecondition(p Precondition) {
+	defaultPreconditions = append(defaultPreconditions, p)
+}
+
+// Result captures the outcome of running a single precondition. Blocking reflects whether
+// this specific result should be treated as blocking by Summarize - normally the same as
+// Precondition.Blocking(), but RunAll forces it to false for every precondition when the
+// instance carries overrideAnnotation
+type Result struct {
+	Precondition Precondition
+	Err          error
+	Blocking     bool
+}
+
+// Failed reports whether this result represents a failed precondition
+func (r Result) Failed() bool {
+	return r.Err != nil
+}
+
+// RunAll executes every registered precondition for the given release context, returning
+// one Result per precondition. It does not stop at the first failure, so callers get a
+// complete picture of what is blocking (or merely concerning) an upgrade.
+//
+// If the instance carries overrideAnnotation (checked directly via isOverridden), every
+// precondition still runs and is still logged, but none of the results are
+// marked as blocking, so Summarize lets the upgrade through.
+func (l List) RunAll(ctx context.Context, c client.Client, rc ReleaseContext) []Result {
+	overridden := isOverridden(rc.Jaeger)
+
+	results := make([]Result, 0, len(l))
+	for _, p := range l {
+		err := p.Run(ctx, c, rc)
+		blocking := p.Blocking() && !overridden
+		if err != nil {
+			log.WithFields(log.Fields{
+				"instance":     rc.Jaeger.Name,
+				"namespace":    rc.Jaeger.Namespace,
+				"precondition": p.Name(),
+				"to":           rc.To,
+				"blocking":     blocking,
+			}).WithError(err).Warn("precondition not satisfied")
+		}
+		results = append(results, Result{Precondition: p, Err: err, Blocking: blocking})
+	}
+	return results
+}
+
+// Summarize returns an error naming the first blocking failure in results, or nil if none
+// of the blocking preconditions failed. Non-blocking failures are not surfaced here; they
+// are only logged by RunAll
+func Summarize(results []Result) error {
+	for _, r := range results {
+		if r.Failed() && r.Blocking {
+			return fmt.Errorf("precondition %q failed: %w", r.Precondition.Name(), r.Err)
+		}
+	}
+	return nil
+}
+
+// isOverridden reports whether the instance has explicitly opted out of blocking
+// preconditions via overrideAnnotation
+func isOverridden(jaeger v1.Jaeger) bool {
+	v, ok := jaeger.Annotations[overrideAnnotation]
+	return ok && v != "false"
+}
+
+// storageReachablePrecondition blocks the upgrade when the storage backend used by the
+// instance cannot be reached, as running the upgrade function against an unreachable
+// storage would leave the instance in an inconsistent state
+type storageReachablePrecondition struct{}
+
+func (storageReachablePrecondition) Name() string { return "storage reachable" }
+
+func (storageReachablePrecondition) Blocking() bool { return true }
+
+func (storageReachablePrecondition) Run(ctx context.Context, c client.Client, rc ReleaseContext) error {
+	if rc.Jaeger.Spec.Storage.Type != v1.JaegerESStorage {
+		// we only know how to probe Elasticsearch today; other storage types are assumed reachable
+		return nil
+	}
+
+	url, ok := rc.Jaeger.Spec.Storage.Options.Map()["es.server-urls"]
+	if !ok {
+		return nil
+	}
+
+	httpClient := http.Client{Timeout: 5 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("could not build request to storage for instance %s/%s: %w", rc.Jaeger.Namespace, rc.Jaeger.Name, err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("storage backend for instance %s/%s is not reachable: %w", rc.Jaeger.Namespace, rc.Jaeger.Name, err)
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// rolloutCompletePrecondition blocks the upgrade while a previous rollout of the instance
+// is still in progress, so we never start a second upgrade on top of one that hasn't
+// settled yet
+type rolloutCompletePrecondition struct{}
+
+func (rolloutCompletePrecondition) Name() string { return "in-flight rollout complete" }
+
+func (rolloutCompletePrecondition) Blocking() bool { return true }
+
+func (rolloutCompletePrecondition) Run(ctx context.Context, c client.Client, rc ReleaseContext) error {
+	if rc.Jaeger.Status.Phase == v1.JaegerPhaseRunning || len(rc.Jaeger.Status.Phase) == 0 {
+		return nil
+	}
+	return fmt.Errorf("instance %s/%s has a rollout in progress (phase %s)", rc.Jaeger.Namespace, rc.Jaeger.Name, rc.Jaeger.Status.Phase)
+}
+
+// noOverridePrecondition reports, purely for visibility in logs and status, whether the
+// instance carries the disable-upgrade-preconditions annotation. It is non-blocking on its
+// own - RunAll is what actually reads overrideAnnotation and, when set, demotes every other
+// precondition's result to non-blocking for this release context, giving admins an
+// explicit, auditable way to force an upgrade through
+type noOverridePrecondition struct{}
+
+func (noOverridePrecondition) Name() string { return "no preconditions override annotation present" }
+
+func (noOverridePrecondition) Blocking() bool { return false }
+
+func (noOverridePrecondition) Run(ctx context.Context, c client.Client, rc ReleaseContext) error {
+	if isOverridden(rc.Jaeger) {
+		return fmt.Errorf("instance %s/%s has %s set", rc.Jaeger.Namespace, rc.Jaeger.Name, overrideAnnotation)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/jaegertracing/jaeger-operator/pkg/apis/jaegertracing/v1"
+)
+
+type stubPrecondition struct {
+	name     string
+	blocking bool
+	err      error
+}
+
+func (s stubPrecondition) Name() string    { return s.name }
+func (s stubPrecondition) Blocking() bool  { return s.blocking }
+func (s stubPrecondition) Run(ctx context.Context, c client.Client, rc ReleaseContext) error {
+	return s.err
+}
+
+func TestSummarizeIgnoresNonBlockingFailures(t *testing.T) {
+	results := List{
+		stubPrecondition{name: "blocking-ok", blocking: true},
+		stubPrecondition{name: "non-blocking-failing", blocking: false, err: errors.New("concerning but not fatal")},
+	}.RunAll(context.Background(), nil, ReleaseContext{})
+
+	assert.NoError(t, Summarize(results))
+}
+
+func TestSummarizeFailsOnBlockingFailure(t *testing.T) {
+	results := List{
+		stubPrecondition{name: "blocking-failing", blocking: true, err: errors.New("storage is down")},
+		stubPrecondition{name: "non-blocking-ok", blocking: false},
+	}.RunAll(context.Background(), nil, ReleaseContext{})
+
+	err := Summarize(results)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "blocking-failing")
+	assert.Contains(t, err.Error(), "storage is down")
+}
+
+func TestRunAllOverrideAnnotationDemotesBlockingResults(t *testing.T) {
+	rc := ReleaseContext{Jaeger: v1.Jaeger{}}
+	rc.Jaeger.Annotations = map[string]string{overrideAnnotation: "true"}
+
+	results := List{
+		stubPrecondition{name: "blocking-failing", blocking: true, err: errors.New("storage is down")},
+	}.RunAll(context.Background(), nil, rc)
+
+	assert.NoError(t, Summarize(results))
+	assert.False(t, results[0].Blocking)
+}
+
+func TestRunAllWithoutOverrideKeepsBlockingResults(t *testing.T) {
+	rc := ReleaseContext{Jaeger: v1.Jaeger{}}
+
+	results := List{
+		stubPrecondition{name: "blocking-failing", blocking: true, err: errors.New("storage is down")},
+	}.RunAll(context.Background(), nil, rc)
+
+	assert.Error(t, Summarize(results))
+	assert.True(t, results[0].Blocking)
+}
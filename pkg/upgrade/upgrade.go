@@ -2,19 +2,35 @@ package upgrade
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"strings"
 
+	"github.com/jaegertracing/jaeger/pkg/tenancy"
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"go.opentelemetry.io/otel/global"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	v1 "github.com/jaegertracing/jaeger-operator/pkg/apis/jaegertracing/v1"
 	"github.com/jaegertracing/jaeger-operator/pkg/tracing"
+	"github.com/jaegertracing/jaeger-operator/pkg/version"
 )
 
+// labelTenant identifies which tenant a Jaeger instance belongs to, for operators that
+// are only responsible for upgrading a subset of tenants during a staged rollout
+const labelTenant = "jaegertracing.io/tenant"
+
+// configTenants is the viper key holding the comma-separated allow-list of tenants this
+// operator instance is responsible for upgrading. An empty/unset value means "all tenants"
+const configTenants = "upgrade.tenants"
+
 // ManagedInstances finds all the Jaeger instances for the current operator and upgrades them, if necessary
 func ManagedInstances(ctx context.Context, c client.Client, reader client.Reader) error {
 	tracer := global.TraceProvider().GetTracer(v1.ReconciliationTracer)
@@ -23,10 +39,23 @@ func ManagedInstances(ctx context.Context, c client.Client, reader client.Reader
 
 	list := &v1.JaegerList{}
 	identity := viper.GetString(v1.ConfigIdentity)
-	opts := []client.ListOption{}
-	opts = append(opts, client.MatchingLabels(map[string]string{
-		v1.LabelOperatedBy: identity,
-	}))
+
+	ownerReq, err := labels.NewRequirement(v1.LabelOperatedBy, selection.Equals, []string{identity})
+	if err != nil {
+		return tracing.HandleError(err, span)
+	}
+	selector := labels.NewSelector().Add(*ownerReq)
+
+	tenants := allowedTenants()
+	if len(tenants) > 0 {
+		tenantReq, err := labels.NewRequirement(labelTenant, selection.In, tenants)
+		if err != nil {
+			return tracing.HandleError(err, span)
+		}
+		selector = selector.Add(*tenantReq)
+	}
+
+	opts := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
 
 	// if set and np cluster scope permission, skip
 	// if not set, treat as true
@@ -65,15 +94,33 @@ func ManagedInstances(ctx context.Context, c client.Client, reader client.Reader
 			continue
 		}
 
-		jaeger, err := ManagedInstance(ctx, c, j)
+		tenant := j.Labels[labelTenant]
+		if len(tenants) > 0 && !contains(tenants, tenant) {
+			log.WithFields(log.Fields{
+				"instance":  j.Name,
+				"namespace": j.Namespace,
+				"tenant":    tenant,
+			}).Debug("skipping CR upgrade as its tenant is not in the allow-list for this operator")
+			continue
+		}
+
+		instanceCtx := ctx
+		if tenant != "" {
+			instanceCtx = tenancy.WithTenant(ctx, tenant)
+		}
+
+		original := j.DeepCopy()
+		jaeger, err := ManagedInstance(instanceCtx, c, j)
 		if err != nil {
 			// nothing to do at this level, just go to the next instance
 			continue
 		}
 
 		if !reflect.DeepEqual(jaeger, j) {
-			// the CR has changed, store it!
-			if err := c.Update(ctx, &jaeger); err != nil {
+			// the CR has changed, store it! we patch rather than update so that a concurrent
+			// reconcile from the main Jaeger controller doesn't get silently overwritten, and
+			// a conflict on one instance doesn't stop us from getting to the rest of the list
+			if err := patchInstance(ctx, c, original, &jaeger); err != nil {
 				log.WithFields(log.Fields{
 					"instance":  jaeger.Name,
 					"namespace": jaeger.Namespace,
@@ -86,30 +133,205 @@ func ManagedInstances(ctx context.Context, c client.Client, reader client.Reader
 	return nil
 }
 
+// patchInstance persists the changes ManagedInstance made to 'updated' relative to
+// 'original', retrying on conflict so a concurrent reconcile from the main Jaeger
+// controller doesn't cause us to lose the upgrade. Spec changes (from per-version upgrade
+// functions that migrate the CR spec) and status changes are patched separately, as the
+// status subresource has its own resourceVersion semantics on most clusters.
+//
+// Each retry re-fetches the current object and builds an optimistic-lock merge patch
+// against that fresh copy before reapplying our change, rather than replaying the same
+// patch against the (now stale) 'original' - otherwise the patch would never actually
+// observe a conflict and RetryOnConflict would have nothing to retry. We only copy over
+// Spec/Status, the fields ManagedInstance actually mutates: re-assigning Labels or
+// Annotations wholesale from 'updated' would blow away any metadata a concurrent reconcile
+// wrote to the freshly-fetched 'current' between our Get and our Patch
+func patchInstance(ctx context.Context, c client.Client, original, updated *v1.Jaeger) error {
+	key := client.ObjectKeyFromObject(original)
+
+	if err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current := &v1.Jaeger{}
+		if err := c.Get(ctx, key, current); err != nil {
+			return err
+		}
+
+		patch := client.MergeFromWithOptions(current.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		current.Spec = updated.Spec
+		return c.Patch(ctx, current, patch)
+	}); err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		current := &v1.Jaeger{}
+		if err := c.Get(ctx, key, current); err != nil {
+			return err
+		}
+
+		statusPatch := client.MergeFromWithOptions(current.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		current.Status = updated.Status
+		return c.Status().Patch(ctx, current, statusPatch)
+	})
+}
+
 // ManagedInstance performs the necessary changes to bring the given Jaeger instance to the current version
 func ManagedInstance(ctx context.Context, client client.Client, jaeger v1.Jaeger) (v1.Jaeger, error) {
 	tracer := global.TraceProvider().GetTracer(v1.ReconciliationTracer)
 	ctx, span := tracer.Start(ctx, "ManagedInstance")
 	defer span.End()
 
-	if v, ok := versions[jaeger.Status.Version]; ok {
-		// we don't need to run the upgrade function for the version 'v', only the next ones
-		for n := v.next; n != nil; n = n.next {
-			// performs the upgrade to version 'n'
-			upgraded, err := n.upgrade(ctx, client, jaeger)
+	if skipUpgrade(jaeger) {
+		recordSkipped(&jaeger)
+		return jaeger, nil
+	}
+
+	pinned, isPinned := pinnedVersion(jaeger)
+	jaeger.Status.UpgradeState.PinnedVersion = pinned
+
+	target := jaeger.Spec.Version
+	if target == "" {
+		target = version.Get().Jaeger
+	}
+	if isPinned {
+		// a pin caps how far we're allowed to go, even if the spec or the operator's own
+		// version would otherwise take this instance further
+		target = pinned
+	}
+	jaeger.Status.UpgradeState.DesiredVersion = target
+
+	from, ok := versions[jaeger.Status.Version]
+	to, targetKnown := versions[target]
+	if ok && !targetKnown {
+		// most commonly a typo in jaegertracing.io/pin-version: surface it instead of
+		// silently leaving the instance parked where it is with no indication why
+		err := fmt.Errorf("target version %q is not a known jaeger version", target)
+		log.WithFields(log.Fields{
+			"instance":  jaeger.Name,
+			"namespace": jaeger.Namespace,
+			"target":    target,
+		}).WithError(err).Warn("cannot upgrade managed instance to an unknown version")
+		setUpgradeableCondition(&jaeger, err)
+		jaeger.Status.UpgradeState.LastAttempt = metav1.Now()
+		jaeger.Status.UpgradeState.LastError = err.Error()
+		return jaeger, tracing.HandleError(err, span)
+	}
+
+	if ok && targetKnown {
+		path, err := pathTo(&from, &to)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"instance":  jaeger.Name,
+				"namespace": jaeger.Namespace,
+				"from":      from.v,
+				"to":        to.v,
+			}).WithError(err).Warn("could not compute an upgrade path for managed instance")
+			jaeger.Status.UpgradeState.LastAttempt = metav1.Now()
+			jaeger.Status.UpgradeState.LastError = err.Error()
+			return jaeger, tracing.HandleError(err, span)
+		}
+
+		if isPinned && len(path) > 0 && !path[0].forward {
+			// pin-version is only a forward ceiling: if the pinned version sits behind where
+			// the instance already is, leave it where it is rather than rolling it back
+			log.WithFields(log.Fields{
+				"instance":  jaeger.Name,
+				"namespace": jaeger.Namespace,
+				"current":   from.v,
+				"pinned":    pinned,
+			}).Debug("pinned version is behind the current version, not downgrading")
+			path = nil
+		}
+
+		previous := from.v
+		for _, h := range path {
+			rc := ReleaseContext{Jaeger: jaeger, From: previous, To: h.to.v}
+			results := defaultPreconditions.RunAll(ctx, client, rc)
+			if err := Summarize(results); err != nil {
+				log.WithFields(log.Fields{
+					"instance":  jaeger.Name,
+					"namespace": jaeger.Namespace,
+					"to":        h.to.v,
+				}).WithError(err).Warn("preconditions not met, aborting upgrade for this instance")
+				setUpgradeableCondition(&jaeger, err)
+				jaeger.Status.UpgradeState.LastAttempt = metav1.Now()
+				jaeger.Status.UpgradeState.LastError = err.Error()
+				return jaeger, tracing.HandleError(err, span)
+			}
+
+			migrate := h.to.upgrade
+			verb := "upgrade"
+			if !h.forward {
+				// a backward hop undoes the version being left, which sits right after 'to'
+				migrate = h.to.next.downgrade
+				verb = "downgrade"
+			}
+
+			migrated, err := migrate(ctx, client, jaeger)
 			if err != nil {
 				log.WithFields(log.Fields{
 					"instance":  jaeger.Name,
 					"namespace": jaeger.Namespace,
-					"to":        n.v,
-				}).WithError(err).Warn("failed to upgrade managed instance")
+					"to":        h.to.v,
+				}).WithError(err).Warnf("failed to %s managed instance", verb)
+				jaeger.Status.UpgradeState.LastAttempt = metav1.Now()
+				jaeger.Status.UpgradeState.LastError = err.Error()
 				return jaeger, tracing.HandleError(err, span)
 			}
 
-			upgraded.Status.Version = n.v
-			jaeger = upgraded
+			// a hop leaves the CR at a well-defined version even if a later hop in the path fails
+			migrated.Status.Version = h.to.v
+			jaeger = migrated
+			previous = h.to.v
 		}
 	}
 
+	jaeger.Status.UpgradeState.LastAttempt = metav1.Now()
+	jaeger.Status.UpgradeState.LastError = ""
+
 	return jaeger, nil
 }
+
+// allowedTenants returns the tenant allow-list configured via the upgrade.tenants viper
+// key, or nil if every tenant is allowed
+func allowedTenants() []string {
+	raw := viper.GetString(configTenants)
+	if raw == "" {
+		return nil
+	}
+
+	tenants := strings.Split(raw, ",")
+	for i := range tenants {
+		tenants[i] = strings.TrimSpace(tenants[i])
+	}
+	return tenants
+}
+
+func contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// setUpgradeableCondition records on the Jaeger status why this instance was not advanced
+// to the next version, so that users inspecting the CR can tell a blocked upgrade apart
+// from one that simply hasn't been attempted yet
+func setUpgradeableCondition(jaeger *v1.Jaeger, err error) {
+	condition := v1.JaegerCondition{
+		Type:               v1.ConditionUpgradeable,
+		Status:             corev1.ConditionFalse,
+		Reason:             "PreconditionFailed",
+		Message:            err.Error(),
+		LastTransitionTime: metav1.Now(),
+	}
+
+	for i, c := range jaeger.Status.Conditions {
+		if c.Type == v1.ConditionUpgradeable {
+			jaeger.Status.Conditions[i] = condition
+			return
+		}
+	}
+	jaeger.Status.Conditions = append(jaeger.Status.Conditions, condition)
+}
@@ -0,0 +1,37 @@
+package upgrade
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/jaegertracing/jaeger-operator/pkg/apis/jaegertracing/v1"
+)
+
+// migrationFunc mutates a Jaeger instance in place, moving it one hop along the upgrade
+// graph. upgrade and downgrade functions share this signature: an upgrade moves the
+// instance to the version it's attached to, a downgrade moves it back to the previous one
+type migrationFunc func(ctx context.Context, client client.Client, jaeger v1.Jaeger) (v1.Jaeger, error)
+
+// version is a single node in the upgrade graph: a known Jaeger version plus the
+// functions that move a CR onto it (upgrade) or off of it towards the previous version
+// (downgrade). prev/next make the graph bidirectional, so ManagedInstance can walk it in
+// either direction to reach a target version below or above the instance's current one
+type version struct {
+	v         string
+	upgrade   migrationFunc
+	downgrade migrationFunc
+	prev      *version
+	next      *version
+}
+
+// versions indexes every version the operator knows how to manage, keyed by its string
+// representation. It is populated by the per-version files in this package (the
+// upgrade_x_y_z.go files) via their init functions, which also wire up prev/next using link
+var versions = map[string]version{}
+
+// link appends 'next' right after 'prev' in the upgrade graph, wiring both directions at once
+func link(prev, next *version) {
+	prev.next = next
+	next.prev = prev
+}